@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/gomatic/renderizer/module"
 	"github.com/imdario/mergo"
 	"github.com/kardianos/osext"
 	"github.com/urfave/cli"
@@ -40,6 +41,12 @@ type Settings struct {
 	Templates []string
 	// Add the environment map to the variables.
 	Environment string
+	// The active environment, selected via --env or RENDERIZER_ENV, used to
+	// pick a layer out of the `environments:` map in the config.
+	Env string
+	// The directory to mirror rendered output into, when rendering a
+	// directory of templates instead of printing to stdout.
+	Out string
 	//
 	OutputExtension string
 	//
@@ -50,6 +57,13 @@ type Settings struct {
 	Debugging bool
 	//
 	Verbose bool
+	// Parse with html/template instead of text/template; auto-enabled when
+	// a template's file extension is .html or .htm.
+	HTML bool
+	// Escape the rendered output for the type detected from the template's
+	// extension (html, js, css). No extension maps to urlquery, so urlquery
+	// escaping is never applied.
+	Safe bool
 }
 
 //
@@ -58,6 +72,7 @@ var settings = Settings{
 	MissingKey:  "error",
 	TimeFormat:  "20060102T150405",
 	Environment: "env",
+	Env:         "default",
 	Config:      map[string]interface{}{},
 	ConfigFiles: []string{},
 	Arguments:   []string{},
@@ -84,6 +99,47 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:  "mod",
+			Usage: "manage template modules (imports and mounts)",
+			Subcommands: []cli.Command{
+				{
+					Name:  "init",
+					Usage: "add a module: section to .renderizer.yaml",
+					Action: func(ctx *cli.Context) error {
+						return module.Init(".renderizer.yaml")
+					},
+				},
+				{
+					Name:  "get",
+					Usage: "resolve and fetch the declared module imports",
+					Action: func(ctx *cli.Context) error {
+						_, err := module.Get(".renderizer.yaml")
+						return err
+					},
+				},
+				{
+					Name:  "graph",
+					Usage: "print the module import graph",
+					Action: func(ctx *cli.Context) error {
+						return module.Graph(".renderizer.yaml")
+					},
+				},
+				{
+					Name:  "tidy",
+					Usage: "remove cached module versions no longer selected",
+					Action: func(ctx *cli.Context) error {
+						return module.Tidy(".renderizer.yaml")
+					},
+				},
+			},
+		},
+		{
+			Name:      "serve",
+			Usage:     "watch a template directory, re-render into --out, and serve it with live-reload",
+			ArgsUsage: "[directory]",
+			Action:    serveCommand,
+		},
 	}
 
 	app.Flags = []cli.Flag{
@@ -101,11 +157,18 @@ func main() {
 			Destination: &settings.MissingKey,
 		},
 		cli.StringFlag{
-			Name:   "environment, env, E, e",
+			Name:   "environment, E, e",
 			Usage:  "load the environment into the variable name instead of as 'env'",
 			Value:  settings.Environment,
 			EnvVar: "RENDERIZER_ENVIRONMENT",
 		},
+		cli.StringFlag{
+			Name:        "env",
+			Usage:       "select the active layer from the config's 'environments:' map (default: \"default\")",
+			Value:       settings.Env,
+			EnvVar:      "RENDERIZER_ENV",
+			Destination: &settings.Env,
+		},
 		cli.BoolFlag{
 			Name:        "stdin, c",
 			Usage:       "read from stdin",
@@ -121,6 +184,22 @@ func main() {
 			Usage:       "enable verbose output",
 			Destination: &settings.Verbose,
 		},
+		cli.BoolFlag{
+			Name:        "html",
+			Usage:       "parse with html/template instead of text/template (auto-enabled for .html/.htm)",
+			Destination: &settings.HTML,
+		},
+		cli.BoolFlag{
+			Name:        "safe",
+			Usage:       "escape the rendered output for its detected type (html, js, css; urlquery is not detected)",
+			Destination: &settings.Safe,
+		},
+		cli.StringFlag{
+			Name:        "out",
+			Usage:       "mirror rendered directory output under this path instead of stdout",
+			EnvVar:      "RENDERIZER_OUT",
+			Destination: &settings.Out,
+		},
 	}
 
 	app.Before = func(ctx *cli.Context) error {
@@ -182,29 +261,46 @@ func main() {
 			settings.ConfigFiles = configs
 		}
 
+		if settings.Debugging || settings.Verbose {
+			log.Printf("using settings: %+v", settings.ConfigFiles)
+		}
+
 		for _, config := range settings.ConfigFiles {
-			in, err := ioutil.ReadFile(config)
-			if err != nil {
+			if err := mergeYAMLFile(&settings.Config, config); err != nil {
 				if !settings.Defaulted {
 					return err
 				}
-			} else {
-				loaded := map[string]interface{}{}
-				err := yaml.Unmarshal(in, &loaded)
-				if err != nil {
-					return err
-				}
-				if settings.Debugging || settings.Verbose {
-					log.Printf("using settings: %+v", settings.ConfigFiles)
+			}
+		}
+
+		// Resolve Helmfile-style layered values: the active environment's
+		// values: files win over the top-level values: files, which win over
+		// the default environment's values: files, which win over the base
+		// config loaded above. Within each values: list, the last file wins.
+		// The top-level values: list is always merged, with or without an
+		// environments: map.
+		topValues := stringSlice(settings.Config["values"])
+		envs, hasEnvs := configMap(settings.Config["environments"])
+
+		if hasEnvs || len(topValues) > 0 {
+			layered := map[string]interface{}{}
+
+			if hasEnvs {
+				if active, ok := configMap(envs[settings.Env]); ok {
+					mergeValuesLayer(&layered, stringSlice(active["values"]))
 				}
-				loaded = retyper(loaded)
-				if settings.Debugging {
-					log.Printf("loaded: %s = %#v", config, loaded)
-				} else if settings.Verbose {
-					log.Printf("loaded: %s = %+v", config, loaded)
+			}
+
+			mergeValuesLayer(&layered, topValues)
+
+			if hasEnvs && settings.Env != "default" {
+				if def, ok := configMap(envs["default"]); ok {
+					mergeValuesLayer(&layered, stringSlice(def["values"]))
 				}
-				mergo.Merge(&settings.Config, loaded)
 			}
+
+			mergo.Merge(&layered, settings.Config)
+			settings.Config = layered
 		}
 
 		if settings.Debugging {
@@ -216,6 +312,19 @@ func main() {
 		return nil
 	}
 
+	// A registered top-level command (e.g. "mod") takes over argument
+	// parsing entirely, skipping the template/value massaging below, which
+	// is only meaningful for the default render action.
+	if len(os.Args) > 1 {
+		for _, command := range app.Commands {
+			if command.HasName(os.Args[1]) {
+				app.Action = renderizer
+				app.Run(os.Args)
+				return
+			}
+		}
+	}
+
 	// Remove args that are not processed by urfave/cli
 	args := []string{os.Args[0]}
 	if len(os.Args) > 1 {
@@ -235,13 +344,13 @@ func main() {
 					flag = parts[0]
 				}
 				switch flag[2:] {
-				case "settings", "missing":
+				case "settings", "missing", "env", "out":
 					// If the flag requires a parameter but it is not specified with an =, grab the next argument too.
 					if !strings.Contains(larg, "=") {
 						next = true
 					}
 					fallthrough
-				case "debug", "verbose", "version", "stdin", "help":
+				case "debug", "verbose", "version", "stdin", "help", "html", "safe":
 					args = append(args, arg)
 					continue
 				}
@@ -269,3 +378,69 @@ func main() {
 	app.Action = renderizer
 	app.Run(args)
 }
+
+// mergeYAMLFile loads a single YAML file and merges it into dst, using the
+// same first-value-wins semantics (via mergo.Merge) as the rest of the
+// config loader.
+func mergeYAMLFile(dst *map[string]interface{}, path string) error {
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	loaded := map[string]interface{}{}
+	if err := yaml.Unmarshal(in, &loaded); err != nil {
+		return err
+	}
+
+	loaded = retyper(loaded)
+	if settings.Debugging {
+		log.Printf("loaded: %s = %#v", path, loaded)
+	} else if settings.Verbose {
+		log.Printf("loaded: %s = %+v", path, loaded)
+	}
+
+	return mergo.Merge(dst, loaded)
+}
+
+// mergeValuesLayer merges each of files into dst in reverse order, so that
+// within a single values: list the last file takes precedence, matching
+// Helmfile's convention.
+func mergeValuesLayer(dst *map[string]interface{}, files []string) {
+	for i := len(files) - 1; i >= 0; i-- {
+		if err := mergeYAMLFile(dst, files[i]); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// configMap coerces a decoded YAML value into a map[string]interface{},
+// accepting both the map[string]interface{} and map[interface{}]interface{}
+// shapes that can come out of a YAML document.
+func configMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		out := map[string]interface{}{}
+		for k, val := range m {
+			out[fmt.Sprintf("%v", k)] = val
+		}
+		return out, true
+	default:
+		return nil, false
+	}
+}
+
+// stringSlice coerces a decoded YAML value into a []string.
+func stringSlice(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, fmt.Sprintf("%v", item))
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package module
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the `module:` section of .renderizer.yaml.
+type Config struct {
+	Imports []Import `yaml:"imports"`
+}
+
+// LoadConfig reads the module: section out of a renderizer config file.
+func LoadConfig(path string) (Config, error) {
+	var doc struct {
+		Module Config `yaml:"module"`
+	}
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := yaml.Unmarshal(in, &doc); err != nil {
+		return Config{}, err
+	}
+	return doc.Module, nil
+}
+
+// Init appends an empty module: skeleton to path, for `renderizer mod
+// init`. It refuses to run if path already declares imports.
+func Init(path string) error {
+	if cfg, err := LoadConfig(path); err == nil && len(cfg.Imports) > 0 {
+		return fmt.Errorf("module: %s already declares a module: section", path)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString("\nmodule:\n  imports: []\n")
+	return err
+}
+
+// Get resolves and fetches every import declared in path's module:
+// section, for `renderizer mod get`.
+func Get(path string) ([]Module, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return Resolve(cfg.Imports)
+}
+
+// Graph prints the import graph for path's module: section to stdout, as
+// "path@version" lines, for `renderizer mod graph`.
+func Graph(path string) error {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	for _, imp := range cfg.Imports {
+		fmt.Printf("%s@%s\n", imp.Path, imp.Version)
+	}
+	return nil
+}
+
+// Tidy removes cached module versions that path's module: section no
+// longer selects, for `renderizer mod tidy`.
+func Tidy(path string) error {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	selected, err := selectVersions(cfg.Imports)
+	if err != nil {
+		return err
+	}
+
+	keep := map[string]bool{}
+	for _, imp := range selected {
+		if !IsLocal(imp.Path) {
+			keep[filepath.Join(sanitize(imp.Path), imp.Version)] = true
+		}
+	}
+
+	root, err := CacheRoot()
+	if err != nil {
+		return err
+	}
+	modDirs, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, modDir := range modDirs {
+		versions, err := ioutil.ReadDir(filepath.Join(root, modDir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, v := range versions {
+			key := filepath.Join(modDir.Name(), v.Name())
+			if !keep[key] {
+				os.RemoveAll(filepath.Join(root, key))
+			}
+		}
+	}
+	return nil
+}
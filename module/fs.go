@@ -0,0 +1,156 @@
+package module
+
+import (
+	"fmt"
+	htemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// FS is the merged virtual filesystem exposed by a resolved set of
+// Modules: each Mount's real directory is addressable under its Target
+// virtual path, e.g. Target "partials" serving a mounted file as
+// "partials/header".
+type FS struct {
+	mounts []virtualMount
+}
+
+type virtualMount struct {
+	target string
+	dir    string
+}
+
+// NewFS builds the merged virtual filesystem for modules, in the order
+// given; earlier modules' mounts take precedence over later ones on a
+// target collision, the same first-wins rule renderizer's config loader
+// uses elsewhere.
+func NewFS(modules []Module) *FS {
+	fs := &FS{}
+	for _, m := range modules {
+		for _, mount := range m.Mounts {
+			fs.mounts = append(fs.mounts, virtualMount{
+				target: strings.Trim(mount.Target, "/"),
+				dir:    filepath.Join(m.Dir, mount.Dir),
+			})
+		}
+	}
+	return fs
+}
+
+// resolve maps a virtual path to its real file path, honoring mount
+// precedence order.
+func (fs *FS) resolve(virtual string) (string, bool) {
+	virtual = strings.Trim(virtual, "/")
+	for _, mount := range fs.mounts {
+		if virtual == mount.target || strings.HasPrefix(virtual, mount.target+"/") {
+			rel := strings.TrimPrefix(strings.TrimPrefix(virtual, mount.target), "/")
+			return filepath.Join(mount.dir, rel), true
+		}
+	}
+	return "", false
+}
+
+// Glob lists every virtual path across all mounts.
+func (fs *FS) Glob() ([]string, error) {
+	var names []string
+	for _, mount := range fs.mounts {
+		err := filepath.Walk(mount.dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(mount.dir, p)
+			if err != nil {
+				return err
+			}
+			names = append(names, path.Join(mount.target, filepath.ToSlash(rel)))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ParseAll parses every file in the virtual filesystem into a single
+// associative *template.Template set, named by virtual path, so
+// `{{ template "partials/header" }}` resolves across module boundaries.
+// Each template is registered under both its full virtual path
+// (`partials/header.html`) and, when that differs, its extension-stripped
+// form (`partials/header`) so callers don't have to spell out the
+// extension; the first file to claim a stripped name wins.
+func (fs *FS) ParseAll(funcs template.FuncMap) (*template.Template, error) {
+	names, err := fs.Glob()
+	if err != nil {
+		return nil, err
+	}
+
+	root := template.New("root").Funcs(funcs)
+	claimed := map[string]bool{}
+	for _, name := range names {
+		real, ok := fs.resolve(name)
+		if !ok {
+			continue
+		}
+		data, err := ioutil.ReadFile(real)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := root.New(name).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("module: %s: %v", name, err)
+		}
+		claimed[name] = true
+
+		stripped := strings.TrimSuffix(name, path.Ext(name))
+		if stripped != name && !claimed[stripped] {
+			if _, err := root.New(stripped).Parse(string(data)); err != nil {
+				return nil, fmt.Errorf("module: %s: %v", stripped, err)
+			}
+			claimed[stripped] = true
+		}
+	}
+	return root, nil
+}
+
+// ParseAllHTML is ParseAll's html/template counterpart, for callers
+// rendering in HTML mode (contextual auto-escaping). The two can't share
+// an implementation since text/template and html/template are distinct,
+// non-interchangeable types.
+func (fs *FS) ParseAllHTML(funcs htemplate.FuncMap) (*htemplate.Template, error) {
+	names, err := fs.Glob()
+	if err != nil {
+		return nil, err
+	}
+
+	root := htemplate.New("root").Funcs(funcs)
+	claimed := map[string]bool{}
+	for _, name := range names {
+		real, ok := fs.resolve(name)
+		if !ok {
+			continue
+		}
+		data, err := ioutil.ReadFile(real)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := root.New(name).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("module: %s: %v", name, err)
+		}
+		claimed[name] = true
+
+		stripped := strings.TrimSuffix(name, path.Ext(name))
+		if stripped != name && !claimed[stripped] {
+			if _, err := root.New(stripped).Parse(string(data)); err != nil {
+				return nil, fmt.Errorf("module: %s: %v", stripped, err)
+			}
+			claimed[stripped] = true
+		}
+	}
+	return root, nil
+}
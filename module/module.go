@@ -0,0 +1,113 @@
+// Package module implements renderizer's Hugo-Modules-style template
+// library system: versioned imports of other template trees, mounted into
+// a virtual filesystem so template directories can be shared and versioned
+// instead of copy-pasted between projects.
+package module
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Mount maps a directory inside a module into a virtual path, e.g. a
+// module's "layouts/" directory mounted at "partials/".
+type Mount struct {
+	Dir    string `yaml:"dir"`
+	Target string `yaml:"target"`
+}
+
+// Import declares one module dependency: a local path or a git URL, plus
+// the semver constraint used for minimal version selection and the mounts
+// that expose its directories under the merged virtual filesystem.
+type Import struct {
+	Path    string  `yaml:"path"`
+	Version string  `yaml:"version"`
+	Mounts  []Mount `yaml:"mounts"`
+}
+
+// Module is a resolved Import: Dir is where its contents actually live on
+// disk, either Import.Path itself (for local imports) or a checkout under
+// the module cache (for git imports).
+type Module struct {
+	Import
+	Dir string
+}
+
+// CacheRoot returns the root directory modules are downloaded into:
+// $XDG_CACHE_HOME/renderizer, or the platform's default cache dir when
+// XDG_CACHE_HOME is unset.
+func CacheRoot() (string, error) {
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "renderizer"), nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "renderizer"), nil
+}
+
+// IsLocal reports whether path names a local directory rather than a
+// versioned git import.
+func IsLocal(path string) bool {
+	return strings.HasPrefix(path, ".") || strings.HasPrefix(path, "/")
+}
+
+// Resolve applies minimal version selection across imports, then fetches
+// (or locates, for local imports) each selected Import, returning the
+// resulting Modules.
+func Resolve(imports []Import) ([]Module, error) {
+	selected, err := selectVersions(imports)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make([]Module, 0, len(selected))
+	for _, imp := range selected {
+		dir, err := fetch(imp)
+		if err != nil {
+			return nil, fmt.Errorf("module: %s@%s: %v", imp.Path, imp.Version, err)
+		}
+		modules = append(modules, Module{Import: imp, Dir: dir})
+	}
+	return modules, nil
+}
+
+// fetch returns the local directory an import's contents live in,
+// downloading it into the module cache first if it's a git import that
+// hasn't been fetched at this version yet.
+func fetch(imp Import) (string, error) {
+	if IsLocal(imp.Path) {
+		return imp.Path, nil
+	}
+
+	root, err := CacheRoot()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(root, sanitize(imp.Path), imp.Version)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("git", "clone", "--depth", "1", "--branch", imp.Version, "https://"+imp.Path, dir)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// sanitize turns a module path into a filesystem-safe cache directory
+// component.
+func sanitize(path string) string {
+	return strings.NewReplacer("/", "-", ":", "-").Replace(path)
+}
@@ -0,0 +1,59 @@
+package module
+
+import (
+	"strconv"
+	"strings"
+)
+
+// selectVersions applies minimal version selection across imports: for
+// each distinct module path, the highest requested version wins, the same
+// rule Go modules' (and Hugo Modules') MVS settles on, just without the
+// build-list graph walk since renderizer modules don't yet declare
+// transitive imports. Local paths carry no version and are not compared:
+// their first declaration is kept.
+func selectVersions(imports []Import) ([]Import, error) {
+	highest := map[string]Import{}
+	order := []string{}
+
+	for _, imp := range imports {
+		prior, seen := highest[imp.Path]
+		if !seen {
+			order = append(order, imp.Path)
+			highest[imp.Path] = imp
+			continue
+		}
+		if !IsLocal(imp.Path) && compareVersions(imp.Version, prior.Version) > 0 {
+			highest[imp.Path] = imp
+		}
+	}
+
+	selected := make([]Import, 0, len(order))
+	for _, path := range order {
+		selected = append(selected, highest[path])
+	}
+	return selected, nil
+}
+
+// compareVersions compares two dotted "vX.Y.Z" semver strings, returning
+// -1, 0, or 1. A segment that is not a plain integer (e.g. "3-beta") is
+// read as 0 and compared as such.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+		if i < len(as) {
+			an, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bn, _ = strconv.Atoi(bs[i])
+		}
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
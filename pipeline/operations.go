@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	htemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	ttemplate "text/template"
+
+	"github.com/gomatic/funcmap"
+	"github.com/russross/blackfriday/v2"
+)
+
+func init() {
+	Register("read", newReadOp)
+	Register("template", newTemplateOp)
+	Register("markdown", newMarkdownOp)
+	Register("layout", newLayoutOp)
+	Register("write", newWriteOp)
+}
+
+// readOp loads an explicit `path`, or ctx.Source when path is unset, into
+// ctx.Data.
+type readOp struct {
+	path string
+}
+
+func newReadOp(config map[string]interface{}) (Operation, error) {
+	op := &readOp{}
+	op.path, _ = config["path"].(string)
+	return op, nil
+}
+
+func (op *readOp) Run(ctx *Context) error {
+	path := op.path
+	if path == "" {
+		path = ctx.Source
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ctx.Data = data
+	return nil
+}
+
+// templateOp executes ctx.Data as a Go template, using text/template by
+// default or html/template when `html: true` is set.
+type templateOp struct {
+	html bool
+}
+
+func newTemplateOp(config map[string]interface{}) (Operation, error) {
+	op := &templateOp{}
+	op.html, _ = config["html"].(bool)
+	return op, nil
+}
+
+func (op *templateOp) Run(ctx *Context) error {
+	var b bytes.Buffer
+	if op.html {
+		tmpl, err := htemplate.New(ctx.Source).Funcs(funcmap.Map).Parse(string(ctx.Data))
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(&b, ctx.Vars); err != nil {
+			return err
+		}
+	} else {
+		tmpl, err := ttemplate.New(ctx.Source).Funcs(funcmap.Map).Parse(string(ctx.Data))
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(&b, ctx.Vars); err != nil {
+			return err
+		}
+	}
+	ctx.Data = b.Bytes()
+	return nil
+}
+
+// markdownOp converts ctx.Data from Markdown to HTML.
+type markdownOp struct{}
+
+func newMarkdownOp(config map[string]interface{}) (Operation, error) {
+	return &markdownOp{}, nil
+}
+
+func (op *markdownOp) Run(ctx *Context) error {
+	ctx.Data = blackfriday.Run(ctx.Data)
+	return nil
+}
+
+// layoutOp wraps ctx.Data as the `.Content` of a parent template file named
+// by `path`.
+type layoutOp struct {
+	path string
+}
+
+func newLayoutOp(config map[string]interface{}) (Operation, error) {
+	path, _ := config["path"].(string)
+	if path == "" {
+		return nil, fmt.Errorf("layout: missing path")
+	}
+	return &layoutOp{path: path}, nil
+}
+
+func (op *layoutOp) Run(ctx *Context) error {
+	data, err := ioutil.ReadFile(op.path)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := htemplate.New(filepath.Base(op.path)).Funcs(funcmap.Map).Parse(string(data))
+	if err != nil {
+		return err
+	}
+
+	vars := map[string]interface{}{}
+	for k, v := range ctx.Vars {
+		vars[k] = v
+	}
+	vars["Content"] = htemplate.HTML(ctx.Data)
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return err
+	}
+	ctx.Data = b.Bytes()
+	return nil
+}
+
+// writeOp writes ctx.Data to an explicit `path`, or ctx.Out when path is
+// unset, creating parent directories as needed.
+type writeOp struct {
+	path string
+}
+
+func newWriteOp(config map[string]interface{}) (Operation, error) {
+	op := &writeOp{}
+	op.path, _ = config["path"].(string)
+	return op, nil
+}
+
+func (op *writeOp) Run(ctx *Context) error {
+	path := op.path
+	if path == "" {
+		path = ctx.Out
+	}
+	if path == "" {
+		return fmt.Errorf("write: no output path")
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(path, ctx.Data, 0644)
+}
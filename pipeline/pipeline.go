@@ -0,0 +1,77 @@
+// Package pipeline implements renderizer's multi-stage "pipeline" mode: a
+// sequence of named operations (read, template, markdown, layout, write, ...)
+// applied to each input file in turn. Operations are registered by name in a
+// package-level registry so new stages can be added without touching the
+// core loop.
+package pipeline
+
+import "fmt"
+
+// Context carries the in-flight state of a single file as it moves through
+// the pipeline. Operations read and replace Data; Vars carries the template
+// variables available to template-like operations.
+type Context struct {
+	// Source is the input path the pipeline was started for.
+	Source string
+	// Data is the current contents of the file as it flows through the
+	// pipeline; each operation consumes the previous stage's Data and
+	// replaces it with its own output.
+	Data []byte
+	// Vars holds the template variables available to template-like
+	// operations (renderizer's globalContext).
+	Vars map[string]interface{}
+	// Out is the destination path; operations may set it (e.g. from their
+	// own config) for a later `write` stage to consume.
+	Out string
+}
+
+// Operation is a single named stage of a pipeline. Run receives the
+// in-flight Context and returns an error to abort the pipeline.
+type Operation interface {
+	Run(ctx *Context) error
+}
+
+// Factory builds an Operation from its YAML config block.
+type Factory func(config map[string]interface{}) (Operation, error)
+
+var registry = map[string]Factory{}
+
+// Register adds an operation Factory under name, so a `op: name` entry in a
+// pipeline: list can construct it. Intended to be called from init().
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+// Step is one entry of a `pipeline:` list: the operation name and its
+// config block.
+type Step struct {
+	Op     string
+	Config map[string]interface{}
+}
+
+// Build constructs the Operation for each Step, in order.
+func Build(steps []Step) ([]Operation, error) {
+	ops := make([]Operation, 0, len(steps))
+	for _, step := range steps {
+		factory, ok := registry[step.Op]
+		if !ok {
+			return nil, fmt.Errorf("pipeline: unknown operation %q", step.Op)
+		}
+		op, err := factory(step.Config)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: %s: %v", step.Op, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// Run executes ops in order against ctx, stopping at the first error.
+func Run(ops []Operation, ctx *Context) error {
+	for _, op := range ops {
+		if err := op.Run(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,31 +3,44 @@ package main
 import (
 	"bytes"
 	"fmt"
+	htemplate "html/template"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
 
 	"github.com/gomatic/funcmap"
+	"github.com/gomatic/renderizer/module"
+	"github.com/gomatic/renderizer/pipeline"
 	"github.com/imdario/mergo"
 	"github.com/urfave/cli"
 )
 
 //
-func renderizer(_ *cli.Context) error {
+func renderizer(ctx *cli.Context) error {
+	status, err := render(ctx)
+	if err != nil {
+		return err
+	}
+	os.Exit(status)
+	return nil
+}
+
+// render does the actual work of renderizer, returning an exit status
+// instead of calling os.Exit so it can also be driven repeatedly by
+// `renderizer serve`'s watch loop.
+func render(_ *cli.Context) (int, error) {
 
 	globalContext := map[string]interface{}{}
-	args := []string{}
 
-	// Iterate the remaining arguments for variable overrides and file names.
+	// Iterate the remaining arguments for variable overrides; the file
+	// names themselves travel via settings.Templates (see main()), not here.
 
 	for a, arg := range settings.Arguments {
-		if len(arg) == 0 {
-			continue
-		} else if arg[0] != '-' {
-			args = append(args, arg)
+		if len(arg) == 0 || arg[0] != '-' {
 			continue
 		}
 
@@ -86,8 +99,11 @@ func renderizer(_ *cli.Context) error {
 		log.Printf("globalContext: %+v", globalContext)
 	}
 
-	// If there's no files, read from stdin.
+	// If there's no files, read from stdin. Otherwise, expand any
+	// directory arguments into the template files they contain.
+	args := settings.Templates
 	files := args
+	outRel := map[string]string{}
 	if len(args) == 0 {
 		stat, _ := os.Stdin.Stat()
 		isTTY := (stat.Mode() & os.ModeCharDevice) != 0
@@ -95,6 +111,12 @@ func renderizer(_ *cli.Context) error {
 			log.Println("source: stdin")
 		}
 		files = []string{""}
+	} else {
+		var err error
+		files, outRel, err = expandDirs(args)
+		if err != nil {
+			return 0, err
+		}
 	}
 
 	// Copy any loaded keys into the globalContext unless they already exist, i.e. they were provided on the command line.
@@ -132,6 +154,54 @@ func renderizer(_ *cli.Context) error {
 		log.Printf("globalContext: %+v", globalContext)
 	}
 
+	// Resolve any @env:/@file:/@exec: secret references (or their YAML
+	// {from: ...} equivalent) now that globalContext holds the fully
+	// merged CLI and config values, but before anything executes a
+	// template against it.
+	resolveSecretRefs(globalContext)
+
+	// If a pipeline: is configured, run it in place of the single-template
+	// behavior below; the latter remains the default otherwise.
+	if steps, ok := pipelineSteps(settings.Config["pipeline"]); ok {
+		return runPipeline(steps, files, outRel, globalContext)
+	}
+
+	return renderFiles(files, outRel, globalContext)
+}
+
+// renderFiles is the default single-template (or directory-mirrored)
+// render loop: parse each file as a Go template against vars and either
+// print it to stdout or write it under settings.Out.
+func renderFiles(files []string, outRel map[string]string, globalContext map[string]interface{}) (int, error) {
+
+	// If a module: is configured, resolve its imports into a merged virtual
+	// filesystem up front, so every file below is parsed as part of that
+	// same associative template set and `{{ template "partials/header" }}`
+	// can reach across module boundaries. text/template and html/template
+	// can't share a parse tree, so build both a module set may be used by
+	// either rendering branch below.
+	var moduleTemplates *template.Template
+	var moduleTemplatesHTML *htemplate.Template
+	if moduleCfg, ok := configMap(settings.Config["module"]); ok {
+		imports := moduleImports(moduleCfg["imports"])
+		mods, err := module.Resolve(imports)
+		if err != nil {
+			log.Println(err)
+		} else {
+			moduleFS := module.NewFS(mods)
+			if tmpl, err := moduleFS.ParseAll(funcmap.Map); err != nil {
+				log.Println(err)
+			} else {
+				moduleTemplates = tmpl
+			}
+			if tmpl, err := moduleFS.ParseAllHTML(funcmap.Map); err != nil {
+				log.Println(err)
+			} else {
+				moduleTemplatesHTML = tmpl
+			}
+		}
+	}
+
 	// Execute each template
 
 	status := 0
@@ -164,29 +234,257 @@ func renderizer(_ *cli.Context) error {
 			}
 			data = f
 
-			tmpl, err := template.New(file).
-				Option(fmt.Sprintf("missingkey=%s", settings.MissingKey)).
-				Funcs(funcmap.Map).
-				Parse(string(data))
-			if err != nil {
-				log.Print(err)
-				return 4
-			}
-
 			var b bytes.Buffer
-			err = tmpl.Execute(&b, globalContext)
-			if err != nil {
-				log.Print(err)
-				return 8
+
+			if settings.HTML || autoHTML(file) {
+				base := htemplate.New(file).Funcs(funcmap.Map)
+				if moduleTemplatesHTML != nil {
+					clone, err := moduleTemplatesHTML.Clone()
+					if err != nil {
+						log.Print(err)
+						return 4
+					}
+					base = clone.New(file)
+				}
+
+				tmpl, err := base.
+					Option(fmt.Sprintf("missingkey=%s", settings.MissingKey)).
+					Parse(string(data))
+				if err != nil {
+					log.Print(err)
+					return 4
+				}
+				if err := tmpl.Execute(&b, globalContext); err != nil {
+					log.Print(err)
+					return 8
+				}
+			} else {
+				base := template.New(file).Funcs(funcmap.Map)
+				if moduleTemplates != nil {
+					clone, err := moduleTemplates.Clone()
+					if err != nil {
+						log.Print(err)
+						return 4
+					}
+					base = clone.New(file)
+				}
+
+				tmpl, err := base.
+					Option(fmt.Sprintf("missingkey=%s", settings.MissingKey)).
+					Parse(string(data))
+				if err != nil {
+					log.Print(err)
+					return 4
+				}
+				if err := tmpl.Execute(&b, globalContext); err != nil {
+					log.Print(err)
+					return 8
+				}
 			}
 
 			data = b.Bytes()
-			fmt.Println(string(data))
+			if settings.Safe {
+				data = escapeForType(data, outputType(file))
+			}
+
+			if settings.Out != "" {
+				rel, ok := outRel[file]
+				if !ok {
+					rel = rewriteExt(filepath.Base(file))
+				}
+				dest := filepath.Join(settings.Out, rel)
+				if err := writeOutput(dest, data); err != nil {
+					log.Println(err)
+					return 16
+				}
+				if settings.Verbose {
+					log.Printf("wrote: %s", dest)
+				}
+			} else {
+				fmt.Println(string(data))
+			}
 
 			return 0
 		}()
 	}
 
-	os.Exit(status)
-	return nil
+	return status, nil
+}
+
+// writeOutput writes data to dest, creating any parent directories it
+// needs along the way.
+func writeOutput(dest string, data []byte) error {
+	if dir := filepath.Dir(dest); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(dest, data, 0644)
+}
+
+// autoHTML reports whether file's extension implies html/template should
+// be used even without an explicit --html flag.
+func autoHTML(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".html", ".htm":
+		return true
+	default:
+		return false
+	}
+}
+
+// outputType maps a file's extension to the escaper --safe should apply;
+// "" means no escaping is applied. There's no file extension that implies
+// a urlquery context, so it's not auto-detected here; callers needing it
+// can still reach htemplate.URLQueryEscaper directly via funcmap.Map.
+func outputType(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".html", ".htm":
+		return "html"
+	case ".js":
+		return "js"
+	case ".css":
+		return "css"
+	default:
+		return ""
+	}
+}
+
+// escapeForType escapes data for kind. html is already contextually
+// escaped by html/template during rendering, so it passes through
+// unescaped here.
+func escapeForType(data []byte, kind string) []byte {
+	switch kind {
+	case "js":
+		var b bytes.Buffer
+		htemplate.JSEscape(&b, data)
+		return b.Bytes()
+	case "css":
+		return []byte(cssEscape(string(data)))
+	default:
+		return data
+	}
+}
+
+// cssEscape escapes s for safe inclusion in a CSS string or identifier,
+// per the CSS2.1 escaping rules: every byte outside the unreserved set is
+// replaced with a `\` hex escape (one or two lowercase hex digits) followed
+// by a space, e.g. `\2e ` or `\a `. html/template has no exported CSS
+// escaper, so this is implemented by hand.
+func cssEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "\\%x ", c)
+		}
+	}
+	return b.String()
+}
+
+// moduleImports converts a decoded `module.imports:` list into
+// module.Import values.
+func moduleImports(raw interface{}) []module.Import {
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	imports := make([]module.Import, 0, len(items))
+	for _, item := range items {
+		cfg, ok := configMap(item)
+		if !ok {
+			continue
+		}
+
+		imp := module.Import{}
+		imp.Path, _ = cfg["path"].(string)
+		imp.Version, _ = cfg["version"].(string)
+
+		mounts, _ := cfg["mounts"].([]interface{})
+		for _, m := range mounts {
+			mountCfg, ok := configMap(m)
+			if !ok {
+				continue
+			}
+			mount := module.Mount{}
+			mount.Dir, _ = mountCfg["dir"].(string)
+			mount.Target, _ = mountCfg["target"].(string)
+			imp.Mounts = append(imp.Mounts, mount)
+		}
+
+		imports = append(imports, imp)
+	}
+	return imports
+}
+
+// pipelineSteps converts a decoded `pipeline:` list into pipeline.Step
+// values, in the order declared. ok is false when there's no pipeline:
+// configured, in which case the caller should fall back to the default
+// single-template behavior.
+func pipelineSteps(raw interface{}) (steps []pipeline.Step, ok bool) {
+	items, isList := raw.([]interface{})
+	if !isList || len(items) == 0 {
+		return nil, false
+	}
+
+	for _, item := range items {
+		config, isMap := configMap(item)
+		if !isMap {
+			continue
+		}
+		op, _ := config["op"].(string)
+		delete(config, "op")
+		steps = append(steps, pipeline.Step{Op: op, Config: config})
+	}
+	return steps, true
+}
+
+// runPipeline builds and runs the configured pipeline against each file,
+// in place of the default single-template render loop. Operations write
+// their own output (e.g. via a `write` step), rather than to stdout; each
+// file gets its own mirrored ctx.Out so a `write` step with no explicit
+// `path` doesn't collide across a directory of inputs. Unlike the default
+// render loop, a pipeline has no stdout fallback, so --out defaults to
+// ./dist (as `serve` does) rather than leaving ctx.Out pointing at the
+// source file itself.
+func runPipeline(rawSteps []pipeline.Step, files []string, outRel map[string]string, vars map[string]interface{}) (int, error) {
+	ops, err := pipeline.Build(rawSteps)
+	if err != nil {
+		log.Println(err)
+		return 16, nil
+	}
+
+	out := settings.Out
+	if out == "" {
+		out = "./dist"
+	}
+
+	status := 0
+	for _, file := range files {
+		rel, ok := outRel[file]
+		if !ok {
+			rel = rewriteExt(filepath.Base(file))
+		}
+
+		dest := filepath.Join(out, rel)
+		if abs, err := filepath.Abs(dest); err == nil {
+			if srcAbs, err := filepath.Abs(file); err == nil && abs == srcAbs {
+				log.Printf("write: refusing to overwrite source file %s", file)
+				status |= 32
+				continue
+			}
+		}
+
+		ctx := &pipeline.Context{Source: file, Vars: vars, Out: dest}
+		if err := pipeline.Run(ops, ctx); err != nil {
+			log.Println(err)
+			status |= 32
+		}
+	}
+
+	return status, nil
 }
\ No newline at end of file
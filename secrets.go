@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// secretRef is a reference to an externally-sourced value: an environment
+// variable, a file's contents, or a command's output. It's how CLI values
+// and .renderizer.yaml can point at secrets (`@env:DB_PASSWORD`, `{from:
+// env, name: DB_PASSWORD}`) instead of embedding them directly.
+type secretRef struct {
+	kind string // "env", "file", or "exec"
+	arg  string
+}
+
+// parseSecretRef recognizes the `@env:NAME`, `@file:PATH`, and
+// `@exec:COMMAND` sentinel syntax used for CLI `--name=value` overrides.
+func parseSecretRef(v string) (secretRef, bool) {
+	for _, kind := range []string{"env", "file", "exec"} {
+		prefix := "@" + kind + ":"
+		if strings.HasPrefix(v, prefix) {
+			return secretRef{kind: kind, arg: strings.TrimPrefix(v, prefix)}, true
+		}
+	}
+	return secretRef{}, false
+}
+
+// parseSecretRefMap recognizes the YAML `{from: env, name: NAME}` (and
+// file/exec equivalents) syntax used in .renderizer.yaml.
+func parseSecretRefMap(m map[string]interface{}) (secretRef, bool) {
+	switch from, _ := m["from"].(string); from {
+	case "env":
+		name, _ := m["name"].(string)
+		return secretRef{kind: "env", arg: name}, true
+	case "file":
+		path, _ := m["path"].(string)
+		return secretRef{kind: "file", arg: path}, true
+	case "exec":
+		command, _ := m["command"].(string)
+		return secretRef{kind: "exec", arg: command}, true
+	default:
+		return secretRef{}, false
+	}
+}
+
+// resolve fetches the value ref points at.
+func (ref secretRef) resolve() (string, error) {
+	switch ref.kind {
+	case "env":
+		v, ok := os.LookupEnv(ref.arg)
+		if !ok {
+			return "", fmt.Errorf("secretref: env %q is not set", ref.arg)
+		}
+		return v, nil
+	case "file":
+		data, err := ioutil.ReadFile(ref.arg)
+		if err != nil {
+			return "", fmt.Errorf("secretref: file %q: %v", ref.arg, err)
+		}
+		return strings.TrimRight(string(data), "\n"), nil
+	case "exec":
+		parts := strings.Fields(ref.arg)
+		if len(parts) == 0 {
+			return "", fmt.Errorf("secretref: exec: empty command")
+		}
+		out, err := exec.Command(parts[0], parts[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("secretref: exec %q: %v", ref.arg, err)
+		}
+		return strings.TrimRight(string(out), "\n"), nil
+	default:
+		return "", fmt.Errorf("secretref: unknown kind %q", ref.kind)
+	}
+}
+
+// resolveValue returns the resolved value of v if it's a secretRef sentinel
+// (a string or a {from: ...} map), logging without ever printing the
+// resolved value itself. ok reports whether v was a sentinel that resolved;
+// when resolution fails the error is logged and v is returned unchanged with
+// ok false.
+func resolveValue(key string, v interface{}) (resolved interface{}, ok bool) {
+	var ref secretRef
+	switch x := v.(type) {
+	case string:
+		ref, ok = parseSecretRef(x)
+	case map[string]interface{}:
+		ref, ok = parseSecretRefMap(x)
+	}
+	if !ok {
+		return v, false
+	}
+
+	value, err := ref.resolve()
+	if err != nil {
+		log.Println(err)
+		return v, false
+	}
+	if settings.Debugging || settings.Verbose {
+		log.Printf("secretref: resolved %s from %s (value not logged)", key, ref.kind)
+	}
+	return value, true
+}
+
+// resolveSecretRefs walks ctx recursively, replacing any secretRef sentinel
+// it finds with its resolved value. It's meant to run after the config
+// merge and CLI overrides, but before template execution, so both
+// `--db.password=@env:DB_PASSWORD` and a YAML `password: {from: env, name:
+// DB_PASSWORD}` are resolved the same way.
+func resolveSecretRefs(ctx map[string]interface{}) {
+	for k, v := range ctx {
+		if resolved, ok := resolveValue(k, v); ok {
+			ctx[k] = resolved
+			continue
+		}
+
+		switch x := v.(type) {
+		case map[string]interface{}:
+			resolveSecretRefs(x)
+		case []interface{}:
+			for i, item := range x {
+				if resolved, ok := resolveValue(k, item); ok {
+					x[i] = resolved
+				} else if m, ok := item.(map[string]interface{}); ok {
+					resolveSecretRefs(m)
+				}
+			}
+		}
+	}
+}
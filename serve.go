@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli"
+)
+
+// liveReloadScript is injected into served .html responses so the browser
+// reloads itself once serve's watcher re-renders the page it's looking at.
+const liveReloadScript = `<script>
+(function() {
+	var seen = null;
+	setInterval(function() {
+		fetch("/__renderizer_reload").then(function(r) { return r.text() }).then(function(tag) {
+			if (seen === null) { seen = tag; return }
+			if (tag !== seen) { location.reload() }
+		})
+	}, 1000)
+})()
+</script>`
+
+// serveCommand implements `renderizer serve [directory]`: render the
+// directory once, then watch it and re-render into --out on every change,
+// serving --out over HTTP with live-reload injected into .html responses.
+func serveCommand(ctx *cli.Context) error {
+	watchDir := "."
+	if args := []string(ctx.Args()); len(args) > 0 {
+		watchDir = args[0]
+	}
+	if settings.Out == "" {
+		settings.Out = "./dist"
+	}
+	settings.Templates = []string{watchDir}
+
+	renderOnce := func() {
+		if _, err := render(ctx); err != nil {
+			log.Println(err)
+		}
+	}
+	renderOnce()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := addRecursiveWatch(watcher, watchDir); err != nil {
+		return err
+	}
+
+	var reloadMu sync.Mutex
+	reloadTag := ""
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if settings.Debugging {
+					log.Printf("watch: %s %s", event.Op, event.Name)
+				}
+				renderOnce()
+				reloadMu.Lock()
+				reloadTag = event.Name + " " + event.Op.String()
+				reloadMu.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println(err)
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/__renderizer_reload", func(w http.ResponseWriter, r *http.Request) {
+		reloadMu.Lock()
+		tag := reloadTag
+		reloadMu.Unlock()
+		fmt.Fprint(w, tag)
+	})
+
+	fileServer := http.FileServer(http.Dir(settings.Out))
+	mux.Handle("/", reloadInjector{fileServer})
+
+	addr := ":8000"
+	log.Printf("serving %s on %s (watching %s)", settings.Out, addr, watchDir)
+	return http.ListenAndServe(addr, mux)
+}
+
+// addRecursiveWatch adds root and every directory beneath it to watcher,
+// so new files in existing subdirectories are picked up too.
+func addRecursiveWatch(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+// reloadInjector wraps a file server, appending liveReloadScript just
+// before </body> in any response so the live-reload poll runs in the
+// browser.
+type reloadInjector struct {
+	next http.Handler
+}
+
+func (h reloadInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := &bufferingResponseWriter{ResponseWriter: w}
+	h.next.ServeHTTP(rec, r)
+	rec.flush()
+}
+
+// bufferingResponseWriter buffers a response so reloadInjector can rewrite
+// the body before it reaches the client.
+type bufferingResponseWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+}
+
+func (w *bufferingResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferingResponseWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *bufferingResponseWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	body := w.buf.Bytes()
+	if idx := bytes.LastIndex(body, []byte("</body>")); idx >= 0 {
+		injected := append([]byte{}, body[:idx]...)
+		injected = append(injected, []byte(liveReloadScript)...)
+		injected = append(injected, body[idx:]...)
+		body = injected
+	}
+
+	w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// renderizerIgnore loads the glob patterns (filepath.Match syntax, one per
+// line, blank lines and # comments skipped) from a .renderizerignore file in
+// dir, if any. Negation (`!`), `**` and trailing-slash patterns are not
+// supported.
+func renderizerIgnore(dir string) []string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".renderizerignore"))
+	if err != nil {
+		return nil
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// ignored reports whether rel, a slash-separated path relative to the
+// .renderizerignore's directory, matches any of patterns.
+func ignored(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walkTemplates recursively collects the file paths under root, skipping
+// anything matched by a .renderizerignore in root.
+func walkTemplates(root string) ([]string, error) {
+	patterns := renderizerIgnore(root)
+
+	var files []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." || rel == ".renderizerignore" {
+			return nil
+		}
+		if ignored(patterns, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// rewriteExt swaps rel's extension for settings.OutputExtension, when set.
+func rewriteExt(rel string) string {
+	if settings.OutputExtension == "" {
+		return rel
+	}
+	return strings.TrimSuffix(rel, filepath.Ext(rel)) + settings.OutputExtension
+}
+
+// expandDirs replaces any directory entries in args with the template
+// files found inside them (recursively, respecting .renderizerignore), and
+// returns each file's path alongside the mirrored output path it should
+// get under settings.Out, with its extension rewritten per
+// settings.OutputExtension.
+func expandDirs(args []string) (files []string, outRel map[string]string, err error) {
+	outRel = map[string]string{}
+
+	for _, arg := range args {
+		info, statErr := os.Stat(arg)
+		if statErr != nil || !info.IsDir() {
+			files = append(files, arg)
+			if statErr == nil {
+				outRel[arg] = rewriteExt(filepath.Base(arg))
+			}
+			continue
+		}
+
+		found, walkErr := walkTemplates(arg)
+		if walkErr != nil {
+			return nil, nil, walkErr
+		}
+		for _, file := range found {
+			rel, relErr := filepath.Rel(arg, file)
+			if relErr != nil {
+				return nil, nil, relErr
+			}
+			files = append(files, file)
+			outRel[file] = rewriteExt(filepath.ToSlash(rel))
+		}
+	}
+	return files, outRel, nil
+}